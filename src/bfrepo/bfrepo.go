@@ -2,10 +2,10 @@ package bfrepo
 
 import (
 	"fmt"
-	"path/filepath"
 	"strings"
 
-	"github.com/BitFunnel/LabBook/src/systems/fs"
+	"github.com/BitFunnel/LabBook/src/bfrepo/builddriver"
+	"github.com/BitFunnel/LabBook/src/bfrepo/gitclient"
 	"github.com/BitFunnel/LabBook/src/systems/shell"
 )
 
@@ -13,12 +13,38 @@ import (
 const bitfunnelHTTPSRemote = `https://github.com/bitfunnel/bitfunnel`
 const bitfunnelSSHRemote = `git@github.com:bitfunnel/bitfunnel.git`
 
+// defaultDeepenDepth is the history depth, counted from the tips of
+// origin's branches, that Checkout fetches to when deepening a shallow
+// clone to reach a sha outside its window.
+const defaultDeepenDepth = 100
+
+// defaultBuildJobs is the default parallelism passed to
+// builddriver.Driver.Build.
+const defaultBuildJobs = 4
+
+// CloneOptions configures an optional shallow clone, passed to
+// Manager.Clone.
+type CloneOptions struct {
+	// Depth limits the clone to the most recent Depth commits; 0 means
+	// a full clone.
+	Depth int
+
+	// SingleBranch clones only the tip of a single branch, rather than
+	// all of the remote's branches.
+	SingleBranch bool
+
+	// Ref is the branch to clone when SingleBranch is set; empty means
+	// the remote's default branch.
+	Ref string
+}
+
 // Manager manages the lifecycle of a BitFunnel repository, everything from
 // cloning, to checking out a specific version, to building BitFunnel, to
 // runinng the REPL.
 type Manager interface {
 	GetPath() string
-	Clone() error
+	GetBuildRoot() string
+	Clone(options CloneOptions) error
 	Fetch() error
 	Checkout(revision string) (shell.CmdHandle, error)
 	ConfigureBuild() error
@@ -30,21 +56,88 @@ type Manager interface {
 }
 
 type bfRepoContext struct {
-	bitFunnelRoot       string
-	buildRoot           string
-	bitFunnelExecutable string
+	bitFunnelRoot   string
+	gitClient       gitclient.Client
+	cloneURL        string
+	acceptedRemotes []string
+	deepenDepth     int
+	buildDriver     builddriver.Driver
+	buildJobs       int
+}
+
+// Option configures optional behavior of a Manager constructed by New.
+type Option func(*bfRepoContext)
+
+// WithGitClient overrides the git backend used by Manager. By
+// default, New uses a go-git-backed client that never shells out to a
+// system `git` binary; pass gitclient.NewShellClient() to restore the
+// previous shell-based behavior.
+func WithGitClient(client gitclient.Client) Option {
+	return func(repo *bfRepoContext) {
+		repo.gitClient = client
+	}
+}
+
+// WithMirror directs Clone to clone from url instead of the canonical
+// BitFunnel remote, while Fetch continues to validate `origin` against
+// the accepted remotes. This lets users clone from a local mirror or
+// proxy cache without relaxing which origins are considered canonical.
+func WithMirror(url string) Option {
+	return func(repo *bfRepoContext) {
+		repo.cloneURL = url
+	}
+}
+
+// WithAcceptedRemotes replaces the ordered list of remote URLs that
+// Fetch will accept as `origin`, in place of the built-in canonical
+// HTTPS and SSH BitFunnel remotes. This lets Fetch succeed against a
+// fork or mirror used for pre-merge experimentation.
+func WithAcceptedRemotes(urls ...string) Option {
+	return func(repo *bfRepoContext) {
+		acceptedRemotes := make([]string, len(urls))
+		for i, url := range urls {
+			acceptedRemotes[i] = strings.ToLower(url)
+		}
+		repo.acceptedRemotes = acceptedRemotes
+	}
+}
+
+// WithBuildDriver overrides the build system used to configure and
+// build a checkout. By default, New uses builddriver.NewMakeDriver(),
+// matching Manager's historical `Configure_Make.sh` + `make -j4`
+// behavior; pass builddriver.NewNinjaDriver() or
+// builddriver.NewPresetDriver(preset, binaryDir) to build with Ninja
+// or a named CMake preset instead.
+func WithBuildDriver(driver builddriver.Driver) Option {
+	return func(repo *bfRepoContext) {
+		repo.buildDriver = driver
+	}
+}
+
+// WithBuildJobs overrides the parallelism passed to the build driver.
+func WithBuildJobs(jobs int) Option {
+	return func(repo *bfRepoContext) {
+		repo.buildJobs = jobs
+	}
 }
 
 // New creates a BfRepo object, to manage a BitFunnel repository.
-func New(bitFunnelRoot string) Manager {
-	buildRoot := filepath.Join(bitFunnelRoot, "build-make")
-	bitFunnelExecutable :=
-		filepath.Join(buildRoot, "tools", "BitFunnel", "src", "BitFunnel")
-	return bfRepoContext{
-		bitFunnelRoot:       bitFunnelRoot,
-		buildRoot:           buildRoot,
-		bitFunnelExecutable: bitFunnelExecutable,
+func New(bitFunnelRoot string, options ...Option) Manager {
+	repo := &bfRepoContext{
+		bitFunnelRoot:   bitFunnelRoot,
+		gitClient:       gitclient.NewGoGitClient(),
+		cloneURL:        bitfunnelHTTPSRemote,
+		acceptedRemotes: []string{bitfunnelHTTPSRemote, bitfunnelSSHRemote},
+		deepenDepth:     defaultDeepenDepth,
+		buildDriver:     builddriver.NewMakeDriver(),
+		buildJobs:       defaultBuildJobs,
+	}
+
+	for _, option := range options {
+		option(repo)
 	}
+
+	return *repo
 }
 
 // GetPath returns the root path of the BitFunnel repository `repo` manages.
@@ -52,119 +145,86 @@ func (repo bfRepoContext) GetPath() string {
 	return repo.bitFunnelRoot
 }
 
-// Clone clones the canonical GitHub repository, into the folder
-// `bitFunnelRoot`.
-func (repo bfRepoContext) Clone() (cloneErr error) {
-	cloneErr =
-		shell.RunCommand("git", "clone", bitfunnelHTTPSRemote, repo.bitFunnelRoot)
-	return
+// GetBuildRoot returns the directory `repo` builds BitFunnel into.
+func (repo bfRepoContext) GetBuildRoot() string {
+	return repo.buildDriver.BuildRoot(repo.bitFunnelRoot)
 }
 
-// Fetch pulls the BitFunnel master from the canonical repository.
-func (repo bfRepoContext) Fetch() error {
-	chdirHandle, chdirErr := fs.ScopedChdir(repo.bitFunnelRoot)
-	if chdirErr != nil {
-		return chdirErr
-	}
-	defer chdirHandle.Dispose()
+// bitFunnelExecutable returns the path to the built BitFunnel tool
+// binary.
+func (repo bfRepoContext) bitFunnelExecutable() string {
+	return repo.buildDriver.Executable(repo.GetBuildRoot())
+}
+
+// Clone clones `cloneURL` into the folder `bitFunnelRoot`, according
+// to `options`. `cloneURL` is the canonical BitFunnel GitHub
+// repository, unless overridden by WithMirror.
+func (repo bfRepoContext) Clone(options CloneOptions) error {
+	return repo.gitClient.Clone(repo.cloneURL, repo.bitFunnelRoot, gitclient.CloneOptions{
+		Depth:        options.Depth,
+		SingleBranch: options.SingleBranch,
+		Ref:          options.Ref,
+	})
+}
 
-	originURL, originURLErr :=
-		shell.CommandOutput("git", "config", "--get", "remote.origin.url")
+// Fetch pulls from the `origin` remote, provided it's one of
+// `acceptedRemotes`.
+func (repo bfRepoContext) Fetch() error {
+	originURL, originURLErr := repo.gitClient.OriginURL(repo.bitFunnelRoot)
 	if originURLErr != nil {
 		return originURLErr
 	}
 
 	lowerOriginURL := strings.ToLower(originURL)
 
-	if lowerOriginURL != bitfunnelSSHRemote &&
-		lowerOriginURL != bitfunnelHTTPSRemote {
-		return fmt.Errorf("The remote 'origin' in the repository located at "+
-			"%s' is required to point at the canonical BitFunnel repository.",
-			repo.bitFunnelRoot)
+	accepted := false
+	for _, acceptedRemote := range repo.acceptedRemotes {
+		if lowerOriginURL == acceptedRemote {
+			accepted = true
+			break
+		}
 	}
 
-	pullErr := shell.RunCommand("git", "fetch", "origin")
-	if pullErr != nil {
-		return pullErr
+	if !accepted {
+		return fmt.Errorf("The remote 'origin' in the repository located at "+
+			"%s' is required to point at one of the accepted BitFunnel "+
+			"remotes: %s.",
+			repo.bitFunnelRoot, strings.Join(repo.acceptedRemotes, ", "))
 	}
-	return nil
+
+	return repo.gitClient.Fetch(repo.bitFunnelRoot)
 }
 
 // Checkout take a path to a canonical BitFunnel repository,
 // `bitFunnelRoot`, and checks out a commit from the canonical GitHub
-// repository, specified by `sha`.
+// repository, specified by `sha`. If `repo` was shallow-cloned and
+// `sha` lies outside the fetched history, Checkout transparently
+// deepens the clone and retries once before giving up.
 func (repo bfRepoContext) Checkout(sha string) (shell.CmdHandle, error) {
-	chdirHandle, chdirErr := fs.ScopedChdir(repo.bitFunnelRoot)
-	if chdirErr != nil {
-		return nil, chdirErr
-	}
-	defer chdirHandle.Dispose()
-
-	// Returns the "short name" of HEAD. Usually this is a branch, like
-	// `master`, but if HEAD is detached, it can also simply be `HEAD`.
-	headRef, headRefErr :=
-		shell.CommandOutput("git", "rev-parse", "--abbrev-ref=strict", "HEAD")
-	if headRefErr != nil {
-		return nil, headRefErr
-	}
-
-	// The commit hash for HEAD.
-	headSha, headShaErr := shell.CommandOutput("git", "rev-parse", "HEAD")
-	if headShaErr != nil {
-		return nil, headShaErr
-	}
-
-	// Checkout commit denoted with `sha`.
-	checkoutErr := shell.RunCommand("git", "checkout", sha)
+	restore, checkoutErr := repo.gitClient.Checkout(repo.bitFunnelRoot, sha)
 	if checkoutErr != nil {
-		return nil, checkoutErr
-	}
-
-	// Set dispose to reset the head when we're done with it.
-	resetHead := func() error {
-		chdirHandle, chdirErr := fs.ScopedChdir(repo.bitFunnelRoot)
-		if chdirErr != nil {
-			return chdirErr
+		if deepenErr := repo.gitClient.Deepen(repo.bitFunnelRoot, repo.deepenDepth); deepenErr != nil {
+			return nil, checkoutErr
 		}
-		defer chdirHandle.Dispose()
 
-		var presentRef string
-		if headRef == "HEAD" {
-			presentRef = headSha
-		} else {
-			presentRef = headRef
+		restore, checkoutErr = repo.gitClient.Checkout(repo.bitFunnelRoot, sha)
+		if checkoutErr != nil {
+			return nil, checkoutErr
 		}
-
-		checkoutErr := shell.RunCommand("git", "checkout", presentRef)
-		return checkoutErr
 	}
 
-	return shell.MakeHandle(resetHead), nil
+	return shell.MakeHandle(restore), nil
 }
 
-// Configure switches to the directory of the BitFunnel root, and runs
-// the configuration script that generates a makefile.
+// ConfigureBuild generates the build files for the BitFunnel
+// checkout, using `buildDriver`.
 func (repo bfRepoContext) ConfigureBuild() error {
-	chdirHandle, chdirErr := fs.ScopedChdir(repo.bitFunnelRoot)
-	if chdirErr != nil {
-		return chdirErr
-	}
-	defer chdirHandle.Dispose()
-
-	configErr := shell.RunCommand("sh", "Configure_Make.sh")
-	return configErr
+	return repo.buildDriver.Configure(repo.bitFunnelRoot)
 }
 
-// Build switches to the BitFunnel build directory, and builds the code.
+// Build builds the BitFunnel checkout, using `buildDriver`.
 func (repo bfRepoContext) Build() error {
-	chdirHandle, chdirErr := fs.ScopedChdir(repo.buildRoot)
-	if chdirErr != nil {
-		return chdirErr
-	}
-	defer chdirHandle.Dispose()
-
-	buildErr := shell.RunCommand("make", "-j4")
-	return buildErr
+	return repo.buildDriver.Build(repo.GetBuildRoot(), repo.buildJobs)
 }
 
 // RunFilter runs the `filter` command in the BitFunnel executable tool.
@@ -179,7 +239,7 @@ func (repo bfRepoContext) RunFilter(configManifestPath string, samplePath string
 		sampleArgs...)
 
 	return shell.RunCommand(
-		repo.bitFunnelExecutable,
+		repo.bitFunnelExecutable(),
 		arguments...)
 }
 
@@ -187,7 +247,7 @@ func (repo bfRepoContext) RunFilter(configManifestPath string, samplePath string
 func (repo bfRepoContext) RunStatistics(statsManifestPath string, configDir string) error {
 	// TODO: Check that this is configured.
 	return shell.RunCommand(
-		repo.bitFunnelExecutable,
+		repo.bitFunnelExecutable(),
 		"statistics",
 		statsManifestPath,
 		configDir,
@@ -197,7 +257,7 @@ func (repo bfRepoContext) RunStatistics(statsManifestPath string, configDir stri
 // RunTermTable runs the `termtable` command in the BitFunnel executable tool.
 func (repo bfRepoContext) RunTermTable(configDir string) error {
 	return shell.RunCommand(
-		repo.bitFunnelExecutable,
+		repo.bitFunnelExecutable(),
 		"termtable",
 		configDir)
 }
@@ -205,7 +265,7 @@ func (repo bfRepoContext) RunTermTable(configDir string) error {
 // RunRepl runs the BitFunnel repl.
 func (repo bfRepoContext) RunRepl(configDir string, scriptFile string) error {
 	return shell.RunCommand(
-		repo.bitFunnelExecutable,
+		repo.bitFunnelExecutable(),
 		"repl",
 		configDir,
 		"-script",
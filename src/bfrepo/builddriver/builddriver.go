@@ -0,0 +1,23 @@
+// Package builddriver abstracts the build system bfrepo.Manager uses
+// to configure and build a checked-out BitFunnel tree, in place of the
+// historical hardcoded `Configure_Make.sh` + `make -j4`.
+package builddriver
+
+// Driver configures and builds a checked-out BitFunnel tree rooted at
+// some `root` directory.
+type Driver interface {
+	// Configure generates build files for the tree rooted at root.
+	Configure(root string) error
+
+	// Build builds the tree previously configured by Configure, using
+	// up to `jobs` parallel jobs.
+	Build(buildRoot string, jobs int) error
+
+	// BuildRoot returns the directory Configure/Build write build
+	// output to, for the BitFunnel checkout rooted at `root`.
+	BuildRoot(root string) string
+
+	// Executable returns the path to the built BitFunnel tool binary,
+	// given the buildRoot returned by BuildRoot.
+	Executable(buildRoot string) string
+}
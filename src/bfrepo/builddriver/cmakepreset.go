@@ -0,0 +1,61 @@
+package builddriver
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/BitFunnel/LabBook/src/systems/fs"
+	"github.com/BitFunnel/LabBook/src/systems/shell"
+)
+
+// presetDriver configures and builds the tree using a named CMake
+// preset declared in CMakePresets.json.
+type presetDriver struct {
+	preset    string
+	binaryDir string
+}
+
+// NewPresetDriver creates a Driver that configures with
+// `cmake --preset=preset` and builds with `cmake --build`. `binaryDir`
+// must match the `binaryDir` the preset itself declares in
+// CMakePresets.json (or CMakeUserPresets.json), with any
+// `${sourceDir}`-style macros already expanded relative to the
+// BitFunnel checkout root -- CMake presets don't declare their output
+// directory by convention (e.g. `build/<preset>` and
+// `out/build/<preset>` are both common), and there's no portable way
+// to query it without invoking the configure step, so it can't be
+// safely inferred from `preset` alone.
+func NewPresetDriver(preset string, binaryDir string) Driver {
+	return presetDriver{preset: preset, binaryDir: binaryDir}
+}
+
+func (driver presetDriver) Configure(root string) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(root)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand("cmake", "--preset", driver.preset)
+}
+
+func (driver presetDriver) Build(buildRoot string, jobs int) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(buildRoot)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand("cmake", "--build", ".", "-j", fmt.Sprintf("%d", jobs))
+}
+
+func (driver presetDriver) BuildRoot(root string) string {
+	if filepath.IsAbs(driver.binaryDir) {
+		return driver.binaryDir
+	}
+	return filepath.Join(root, driver.binaryDir)
+}
+
+func (presetDriver) Executable(buildRoot string) string {
+	return filepath.Join(buildRoot, "tools", "BitFunnel", "src", "BitFunnel")
+}
@@ -0,0 +1,48 @@
+package builddriver
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/BitFunnel/LabBook/src/systems/fs"
+	"github.com/BitFunnel/LabBook/src/systems/shell"
+)
+
+// makeDriver is the original build system: `Configure_Make.sh`
+// generates a Makefile under build-make/, and `make -j<jobs>` builds
+// it.
+type makeDriver struct{}
+
+// NewMakeDriver creates the default Driver, matching Manager's
+// historical behavior.
+func NewMakeDriver() Driver {
+	return makeDriver{}
+}
+
+func (makeDriver) Configure(root string) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(root)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand("sh", "Configure_Make.sh")
+}
+
+func (makeDriver) Build(buildRoot string, jobs int) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(buildRoot)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand("make", fmt.Sprintf("-j%d", jobs))
+}
+
+func (makeDriver) BuildRoot(root string) string {
+	return filepath.Join(root, "build-make")
+}
+
+func (makeDriver) Executable(buildRoot string) string {
+	return filepath.Join(buildRoot, "tools", "BitFunnel", "src", "BitFunnel")
+}
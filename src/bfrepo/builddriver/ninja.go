@@ -0,0 +1,47 @@
+package builddriver
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/BitFunnel/LabBook/src/systems/fs"
+	"github.com/BitFunnel/LabBook/src/systems/shell"
+)
+
+// ninjaDriver configures the tree with CMake's Ninja generator, under
+// build-ninja/, and builds it with ninja.
+type ninjaDriver struct{}
+
+// NewNinjaDriver creates a Driver that configures with
+// `cmake -G Ninja` and builds with `ninja`.
+func NewNinjaDriver() Driver {
+	return ninjaDriver{}
+}
+
+func (ninjaDriver) Configure(root string) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(root)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand("cmake", "-G", "Ninja", "-B", "build-ninja")
+}
+
+func (ninjaDriver) Build(buildRoot string, jobs int) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(buildRoot)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand("ninja", "-j", fmt.Sprintf("%d", jobs))
+}
+
+func (ninjaDriver) BuildRoot(root string) string {
+	return filepath.Join(root, "build-ninja")
+}
+
+func (ninjaDriver) Executable(buildRoot string) string {
+	return filepath.Join(buildRoot, "tools", "BitFunnel", "src", "BitFunnel")
+}
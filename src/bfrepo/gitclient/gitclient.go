@@ -0,0 +1,45 @@
+// Package gitclient abstracts the git operations that bfrepo.Manager
+// needs, so that the manager doesn't care whether they're carried out
+// by shelling out to a system `git` binary or by an in-process,
+// pure-Go implementation.
+package gitclient
+
+// CloneOptions configures an optional shallow clone.
+type CloneOptions struct {
+	// Depth limits the clone to the most recent Depth commits; 0 means
+	// a full clone.
+	Depth int
+
+	// SingleBranch clones only the tip of a single branch, rather than
+	// all of the remote's branches.
+	SingleBranch bool
+
+	// Ref is the branch to clone when SingleBranch is set; empty means
+	// the remote's default branch.
+	Ref string
+}
+
+// Client performs the git operations needed to manage a checked-out
+// BitFunnel repository.
+type Client interface {
+	// Clone clones remoteURL into path, according to options.
+	Clone(remoteURL string, path string, options CloneOptions) error
+
+	// Fetch fetches from the "origin" remote of the repository at path.
+	Fetch(path string) error
+
+	// Deepen fetches additional history for the shallow-cloned
+	// repository at path, so that Checkout can reach commits outside
+	// the existing shallow window.
+	Deepen(path string, depth int) error
+
+	// Checkout checks out sha in the repository at path. It returns a
+	// function that restores whatever was checked out before the call,
+	// mirroring the HEAD-save/restore behavior callers expect from
+	// `shell.CmdHandle`.
+	Checkout(path string, sha string) (restore func() error, err error)
+
+	// OriginURL returns the URL configured for the "origin" remote of
+	// the repository at path.
+	OriginURL(path string) (string, error)
+}
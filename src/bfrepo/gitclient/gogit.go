@@ -0,0 +1,125 @@
+package gitclient
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// goGitClient implements Client with go-git, an in-process, pure-Go
+// git implementation. Unlike shellClient, it doesn't depend on a
+// system `git` binary, and it's scoped to a repository path rather
+// than the process's current working directory, which is what makes
+// its Checkout's HEAD-save/restore logic testable without
+// `fs.ScopedChdir`.
+type goGitClient struct{}
+
+// NewGoGitClient creates a Client backed by go-git.
+func NewGoGitClient() Client {
+	return goGitClient{}
+}
+
+func (goGitClient) Clone(remoteURL string, path string, options CloneOptions) error {
+	cloneOptions := &git.CloneOptions{
+		URL:          remoteURL,
+		Depth:        options.Depth,
+		SingleBranch: options.SingleBranch,
+	}
+	if options.Ref != "" {
+		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(options.Ref)
+	}
+
+	_, cloneErr := git.PlainClone(path, false, cloneOptions)
+	return cloneErr
+}
+
+func (goGitClient) Fetch(path string) error {
+	repo, openErr := git.PlainOpen(path)
+	if openErr != nil {
+		return openErr
+	}
+
+	fetchErr := repo.Fetch(&git.FetchOptions{RemoteName: "origin"})
+	if fetchErr != nil && fetchErr != git.NoErrAlreadyUpToDate {
+		return fetchErr
+	}
+	return nil
+}
+
+// Deepen fetches the "origin" remote of the shallow-cloned repository
+// at `path` with history `depth` commits deep from the remote's tips.
+func (goGitClient) Deepen(path string, depth int) error {
+	repo, openErr := git.PlainOpen(path)
+	if openErr != nil {
+		return openErr
+	}
+
+	fetchErr := repo.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		Depth:      depth,
+	})
+	if fetchErr != nil && fetchErr != git.NoErrAlreadyUpToDate {
+		return fetchErr
+	}
+	return nil
+}
+
+// Checkout take a path to a repository, `path`, and checks out the
+// commit specified by `sha`, returning a function that restores
+// whatever was checked out beforehand.
+func (goGitClient) Checkout(path string, sha string) (func() error, error) {
+	repo, openErr := git.PlainOpen(path)
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	head, headErr := repo.Head()
+	if headErr != nil {
+		return nil, headErr
+	}
+
+	worktree, worktreeErr := repo.Worktree()
+	if worktreeErr != nil {
+		return nil, worktreeErr
+	}
+
+	checkoutErr := worktree.Checkout(&git.CheckoutOptions{
+		Hash: plumbing.NewHash(sha),
+	})
+	if checkoutErr != nil {
+		return nil, checkoutErr
+	}
+
+	// Restore HEAD when we're done with it. Branch and Hash are
+	// mutually exclusive in go-git's CheckoutOptions, so only set one:
+	// Branch when HEAD was on a branch, Hash when it was detached.
+	restore := func() error {
+		if head.Name().IsBranch() {
+			return worktree.Checkout(&git.CheckoutOptions{Branch: head.Name()})
+		}
+		return worktree.Checkout(&git.CheckoutOptions{Hash: head.Hash()})
+	}
+
+	return restore, nil
+}
+
+func (goGitClient) OriginURL(path string) (string, error) {
+	repo, openErr := git.PlainOpen(path)
+	if openErr != nil {
+		return "", openErr
+	}
+
+	origin, remoteErr := repo.Remote("origin")
+	if remoteErr != nil {
+		return "", remoteErr
+	}
+
+	urls := origin.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("the remote 'origin' in the repository located "+
+			"at %s has no configured URL", path)
+	}
+
+	return urls[0], nil
+}
@@ -0,0 +1,125 @@
+package gitclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func writeFileAndCommit(t *testing.T, worktree *git.Worktree, path string, content string) plumbing.Hash {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	if _, err := worktree.Add(filepath.Base(path)); err != nil {
+		t.Fatalf("adding file: %v", err)
+	}
+
+	sha, err := worktree.Commit(content, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0)},
+	})
+	if err != nil {
+		t.Fatalf("committing: %v", err)
+	}
+
+	return sha
+}
+
+func TestGoGitClientCheckoutRestoresBranchHEAD(t *testing.T) {
+	root := t.TempDir()
+
+	repo, initErr := git.PlainInit(root, false)
+	if initErr != nil {
+		t.Fatalf("initializing repo: %v", initErr)
+	}
+
+	worktree, worktreeErr := repo.Worktree()
+	if worktreeErr != nil {
+		t.Fatalf("getting worktree: %v", worktreeErr)
+	}
+
+	filePath := filepath.Join(root, "file.txt")
+	firstSha := writeFileAndCommit(t, worktree, filePath, "first")
+	secondSha := writeFileAndCommit(t, worktree, filePath, "second")
+
+	client := NewGoGitClient()
+
+	restore, checkoutErr := client.Checkout(root, firstSha.String())
+	if checkoutErr != nil {
+		t.Fatalf("checkout: %v", checkoutErr)
+	}
+
+	if head, headErr := repo.Head(); headErr != nil {
+		t.Fatalf("getting head: %v", headErr)
+	} else if head.Hash() != firstSha {
+		t.Fatalf("expected HEAD at %s, got %s", firstSha, head.Hash())
+	}
+
+	if restoreErr := restore(); restoreErr != nil {
+		t.Fatalf("restore: %v", restoreErr)
+	}
+
+	head, headErr := repo.Head()
+	if headErr != nil {
+		t.Fatalf("getting head: %v", headErr)
+	}
+	if head.Hash() != secondSha {
+		t.Fatalf("expected HEAD restored to %s, got %s", secondSha, head.Hash())
+	}
+	if !head.Name().IsBranch() {
+		t.Fatalf("expected HEAD to be a branch after restore, got %s", head.Name())
+	}
+}
+
+func TestGoGitClientCheckoutRestoresDetachedHEAD(t *testing.T) {
+	root := t.TempDir()
+
+	repo, initErr := git.PlainInit(root, false)
+	if initErr != nil {
+		t.Fatalf("initializing repo: %v", initErr)
+	}
+
+	worktree, worktreeErr := repo.Worktree()
+	if worktreeErr != nil {
+		t.Fatalf("getting worktree: %v", worktreeErr)
+	}
+
+	filePath := filepath.Join(root, "file.txt")
+	firstSha := writeFileAndCommit(t, worktree, filePath, "first")
+	secondSha := writeFileAndCommit(t, worktree, filePath, "second")
+
+	// Detach HEAD at secondSha before exercising Checkout, so restore
+	// has to reproduce a detached HEAD rather than a branch.
+	if checkoutErr := worktree.Checkout(&git.CheckoutOptions{Hash: secondSha}); checkoutErr != nil {
+		t.Fatalf("detaching HEAD: %v", checkoutErr)
+	}
+
+	client := NewGoGitClient()
+
+	restore, checkoutErr := client.Checkout(root, firstSha.String())
+	if checkoutErr != nil {
+		t.Fatalf("checkout: %v", checkoutErr)
+	}
+
+	if restoreErr := restore(); restoreErr != nil {
+		t.Fatalf("restore: %v", restoreErr)
+	}
+
+	head, headErr := repo.Head()
+	if headErr != nil {
+		t.Fatalf("getting head: %v", headErr)
+	}
+	if head.Hash() != secondSha {
+		t.Fatalf("expected HEAD restored to %s, got %s", secondSha, head.Hash())
+	}
+	if head.Name().IsBranch() {
+		t.Fatalf("expected HEAD to remain detached after restore, got %s", head.Name())
+	}
+}
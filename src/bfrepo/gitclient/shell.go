@@ -0,0 +1,117 @@
+package gitclient
+
+import (
+	"fmt"
+
+	"github.com/BitFunnel/LabBook/src/systems/fs"
+	"github.com/BitFunnel/LabBook/src/systems/shell"
+)
+
+// shellClient implements Client by shelling out to a system `git`
+// binary, scoping each operation to `path` via `fs.ScopedChdir`.
+type shellClient struct{}
+
+// NewShellClient creates a Client that shells out to a system git
+// binary. This is the original, pre-go-git behavior, kept around for
+// environments that don't have go-git's feature coverage (e.g. some
+// LFS or submodule workflows).
+func NewShellClient() Client {
+	return shellClient{}
+}
+
+func (shellClient) Clone(remoteURL string, path string, options CloneOptions) error {
+	arguments := []string{"clone"}
+	if options.Depth > 0 {
+		arguments = append(arguments, "--depth", fmt.Sprintf("%d", options.Depth))
+	}
+	if options.SingleBranch {
+		arguments = append(arguments, "--single-branch")
+	}
+	if options.Ref != "" {
+		arguments = append(arguments, "--branch", options.Ref)
+	}
+	arguments = append(arguments, remoteURL, path)
+
+	return shell.RunCommand("git", arguments...)
+}
+
+func (shellClient) Fetch(path string) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(path)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand("git", "fetch", "origin")
+}
+
+// Deepen fetches the "origin" remote of the shallow-cloned repository
+// at `path` with history `depth` commits deep from the remote's tips.
+func (shellClient) Deepen(path string, depth int) error {
+	chdirHandle, chdirErr := fs.ScopedChdir(path)
+	if chdirErr != nil {
+		return chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.RunCommand(
+		"git", "fetch", fmt.Sprintf("--depth=%d", depth), "origin")
+}
+
+// Checkout take a path to a repository, `path`, and checks out the
+// commit specified by `sha`, returning a function that restores
+// whatever was checked out beforehand.
+func (shellClient) Checkout(path string, sha string) (func() error, error) {
+	chdirHandle, chdirErr := fs.ScopedChdir(path)
+	if chdirErr != nil {
+		return nil, chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	// Returns the "short name" of HEAD. Usually this is a branch, like
+	// `master`, but if HEAD is detached, it can also simply be `HEAD`.
+	headRef, headRefErr :=
+		shell.CommandOutput("git", "rev-parse", "--abbrev-ref=strict", "HEAD")
+	if headRefErr != nil {
+		return nil, headRefErr
+	}
+
+	// The commit hash for HEAD.
+	headSha, headShaErr := shell.CommandOutput("git", "rev-parse", "HEAD")
+	if headShaErr != nil {
+		return nil, headShaErr
+	}
+
+	// Checkout commit denoted with `sha`.
+	if checkoutErr := shell.RunCommand("git", "checkout", sha); checkoutErr != nil {
+		return nil, checkoutErr
+	}
+
+	// Restore HEAD when we're done with it.
+	restore := func() error {
+		chdirHandle, chdirErr := fs.ScopedChdir(path)
+		if chdirErr != nil {
+			return chdirErr
+		}
+		defer chdirHandle.Dispose()
+
+		presentRef := headRef
+		if headRef == "HEAD" {
+			presentRef = headSha
+		}
+
+		return shell.RunCommand("git", "checkout", presentRef)
+	}
+
+	return restore, nil
+}
+
+func (shellClient) OriginURL(path string) (string, error) {
+	chdirHandle, chdirErr := fs.ScopedChdir(path)
+	if chdirErr != nil {
+		return "", chdirErr
+	}
+	defer chdirHandle.Dispose()
+
+	return shell.CommandOutput("git", "config", "--get", "remote.origin.url")
+}
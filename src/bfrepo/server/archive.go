@@ -0,0 +1,93 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/BitFunnel/LabBook/src/experiment/file/lock"
+)
+
+// tarDirectory writes a tar+gzip archive of every regular file under
+// `root` to `destPath`, with archive entry names relative to `root`.
+// It writes to a temporary file alongside `destPath` and renames it
+// into place only once the archive is complete, so a failure partway
+// through (a vanishing file, a full disk) never leaves a truncated
+// tarball at `destPath` to poison the cache.
+func tarDirectory(root string, destPath string) error {
+	tmpPath := destPath + ".tmp"
+
+	destFile, createErr := os.Create(tmpPath)
+	if createErr != nil {
+		return createErr
+	}
+
+	writeErr := writeTarball(destFile, root)
+	closeErr := destFile.Close()
+
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return writeErr
+	}
+	if closeErr != nil {
+		os.Remove(tmpPath)
+		return closeErr
+	}
+
+	return os.Rename(tmpPath, destPath)
+}
+
+func writeTarball(destFile *os.File, root string) error {
+	gzipWriter := gzip.NewWriter(destFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	walkErr := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return relErr
+		}
+
+		header, headerErr := tar.FileInfoHeader(info, "")
+		if headerErr != nil {
+			return headerErr
+		}
+		header.Name = filepath.ToSlash(relPath)
+
+		if writeHeaderErr := tarWriter.WriteHeader(header); writeHeaderErr != nil {
+			return writeHeaderErr
+		}
+
+		file, openErr := os.Open(path)
+		if openErr != nil {
+			return openErr
+		}
+		defer file.Close()
+
+		_, copyErr := io.Copy(tarWriter, file)
+		return copyErr
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	if closeErr := tarWriter.Close(); closeErr != nil {
+		return closeErr
+	}
+
+	return gzipWriter.Close()
+}
+
+// writeManifestJSON writes `manifest` to `w` as JSON.
+func writeManifestJSON(w io.Writer, manifest lock.Manifest) error {
+	return json.NewEncoder(w).Encode(manifest)
+}
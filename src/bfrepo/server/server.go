@@ -0,0 +1,226 @@
+// Package server exposes built BitFunnel binaries and configured
+// corpora over HTTP as content-addressed tarballs, so a lab with
+// several machines can run one "builder" node and have workers pull
+// pre-built artifacts keyed by content hash instead of each machine
+// re-cloning and re-compiling BitFunnel.
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/BitFunnel/LabBook/src/bfrepo"
+	"github.com/BitFunnel/LabBook/src/experiment/file/lock"
+)
+
+// ConfigResolver maps a config lock.Manager's Signature to the
+// directory holding the files it covers, and to its Manifest (if one
+// was recorded via lock.Backup).
+type ConfigResolver interface {
+	ConfigDir(signature string) (string, bool)
+	ConfigManifest(signature string) (lock.Manifest, bool)
+}
+
+// Server serves build and config tarballs built from `repo`, caching
+// them on disk under `cacheDir` and coalescing concurrent requests for
+// the same revision into a single Checkout+Build via singleflight.
+// Because `repo` is a single shared working tree, `repoMu` serializes
+// the whole Checkout+ConfigureBuild+Build+tar sequence across *all*
+// shas: singleflight alone only coalesces duplicate requests for the
+// same sha, but does nothing to stop two different shas from
+// interleaving checkouts into the same tree.
+type Server struct {
+	repo       bfrepo.Manager
+	configs    ConfigResolver
+	cacheDir   string
+	addr       string
+	buildGroup singleflight.Group
+	repoMu     sync.Mutex
+}
+
+// New creates a Server that serves artifacts produced by `repo`,
+// listening on `addr` once ListenAndServe is called, and caching
+// tarballs under `cacheDir`.
+func New(repo bfrepo.Manager, configs ConfigResolver, cacheDir string, addr string) *Server {
+	return &Server{
+		repo:     repo,
+		configs:  configs,
+		cacheDir: cacheDir,
+		addr:     addr,
+	}
+}
+
+// ListenAndServe registers the `/build/`, `/config/`, and
+// `/manifest/` endpoints and blocks serving them on `addr`.
+func (server *Server) ListenAndServe() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/build/", server.handleBuild)
+	mux.HandleFunc("/config/", server.handleConfig)
+	mux.HandleFunc("/manifest/", server.handleManifest)
+
+	return http.ListenAndServe(server.addr, mux)
+}
+
+// handleBuild serves /build/<sha>.tar.gz: a tar of the build output
+// for the checked-out revision `sha`, built on cache miss.
+func (server *Server) handleBuild(w http.ResponseWriter, r *http.Request) {
+	sha, ok := trimRoute(r.URL.Path, "/build/", ".tar.gz")
+	if !ok {
+		http.Error(w, "expected /build/<sha>.tar.gz", http.StatusBadRequest)
+		return
+	}
+
+	tarballPath, buildErr := server.buildTarball(sha)
+	if buildErr != nil {
+		http.Error(w, buildErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeFile(w, r, tarballPath)
+}
+
+// handleConfig serves /config/<signature>.tar.gz: a tar of the config
+// directory whose lock.Manager.Signature() is `signature`.
+func (server *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	signature, ok := trimRoute(r.URL.Path, "/config/", ".tar.gz")
+	if !ok {
+		http.Error(w, "expected /config/<signature>.tar.gz", http.StatusBadRequest)
+		return
+	}
+
+	configDir, found := server.configs.ConfigDir(signature)
+	if !found {
+		http.Error(w, fmt.Sprintf("no config with signature %s", signature), http.StatusNotFound)
+		return
+	}
+
+	tarballPath, tarErr := server.configTarball(signature, configDir)
+	if tarErr != nil {
+		http.Error(w, tarErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeFile(w, r, tarballPath)
+}
+
+// handleManifest serves /manifest/<signature>.json: the lock.Manifest
+// recorded for the config with that signature.
+func (server *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	signature, ok := trimRoute(r.URL.Path, "/manifest/", ".json")
+	if !ok {
+		http.Error(w, "expected /manifest/<signature>.json", http.StatusBadRequest)
+		return
+	}
+
+	manifest, found := server.configs.ConfigManifest(signature)
+	if !found {
+		http.Error(w, fmt.Sprintf("no manifest with signature %s", signature), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if encodeErr := writeManifestJSON(w, manifest); encodeErr != nil {
+		http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+// buildTarball returns the cached path to a tarball of the build
+// output for `sha`, building it first on cache miss. Concurrent
+// requests for the same `sha` are coalesced into a single build.
+func (server *Server) buildTarball(sha string) (string, error) {
+	tarballPath := filepath.Join(server.cacheDir, fmt.Sprintf("build-%s.tar.gz", sha))
+	if _, statErr := os.Stat(tarballPath); statErr == nil {
+		return tarballPath, nil
+	}
+
+	result, buildErr, _ := server.buildGroup.Do(sha, func() (interface{}, error) {
+		if _, statErr := os.Stat(tarballPath); statErr == nil {
+			return tarballPath, nil
+		}
+
+		// Only one Checkout+ConfigureBuild+Build+tar sequence may run
+		// against the shared repo at a time, regardless of sha.
+		server.repoMu.Lock()
+		defer server.repoMu.Unlock()
+
+		if _, statErr := os.Stat(tarballPath); statErr == nil {
+			return tarballPath, nil
+		}
+
+		checkoutHandle, checkoutErr := server.repo.Checkout(sha)
+		if checkoutErr != nil {
+			return nil, checkoutErr
+		}
+		defer checkoutHandle.Dispose()
+
+		if configureErr := server.repo.ConfigureBuild(); configureErr != nil {
+			return nil, configureErr
+		}
+
+		if buildErr := server.repo.Build(); buildErr != nil {
+			return nil, buildErr
+		}
+
+		if mkdirErr := os.MkdirAll(server.cacheDir, 0755); mkdirErr != nil {
+			return nil, mkdirErr
+		}
+
+		if tarErr := tarDirectory(server.repo.GetBuildRoot(), tarballPath); tarErr != nil {
+			return nil, tarErr
+		}
+
+		return tarballPath, nil
+	})
+	if buildErr != nil {
+		return "", buildErr
+	}
+
+	return result.(string), nil
+}
+
+// configTarball returns the cached path to a tarball of `configDir`,
+// building it first on cache miss.
+func (server *Server) configTarball(signature string, configDir string) (string, error) {
+	tarballPath := filepath.Join(server.cacheDir, fmt.Sprintf("config-%s.tar.gz", signature))
+	if _, statErr := os.Stat(tarballPath); statErr == nil {
+		return tarballPath, nil
+	}
+
+	result, tarErr, _ := server.buildGroup.Do("config-"+signature, func() (interface{}, error) {
+		if _, statErr := os.Stat(tarballPath); statErr == nil {
+			return tarballPath, nil
+		}
+
+		if mkdirErr := os.MkdirAll(server.cacheDir, 0755); mkdirErr != nil {
+			return nil, mkdirErr
+		}
+
+		if err := tarDirectory(configDir, tarballPath); err != nil {
+			return nil, err
+		}
+
+		return tarballPath, nil
+	})
+	if tarErr != nil {
+		return "", tarErr
+	}
+
+	return result.(string), nil
+}
+
+// trimRoute strips `prefix` and `suffix` from `path`, reporting
+// whether both were present and the remainder is non-empty.
+func trimRoute(path string, prefix string, suffix string) (string, bool) {
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(path, prefix), suffix)
+	return trimmed, trimmed != ""
+}
@@ -0,0 +1,354 @@
+package lock
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// manifestSchemaVersion is the version of the Manifest format written
+// alongside a Backup archive. Bump this if Manifest's fields change in
+// a way that isn't backwards compatible.
+const manifestSchemaVersion = 1
+
+// sha512Algorithm is the only object hash algorithm Backup currently
+// writes, but Manifest.HashAlgorithm is recorded so a future, cheaper
+// algorithm can be introduced without breaking old manifests.
+const sha512Algorithm = "sha512"
+
+// Kind identifies which flavor of lock.Manager a Manifest was taken
+// from (see the LOCKING PROTOCOL comment in lock.go): corpus, sample,
+// config, or experiment.
+type Kind string
+
+// The kinds of lock.Manager that Backup/Restore know how to label.
+const (
+	CorpusKind     Kind = "corpus"
+	SampleKind     Kind = "sample"
+	ConfigKind     Kind = "config"
+	ExperimentKind Kind = "experiment"
+)
+
+// FileEntry describes a single file inside a Backup archive.
+type FileEntry struct {
+	// Path is relative to the root directory that was backed up.
+	Path string `toml:"path"`
+	Size int64  `toml:"size"`
+	Hash string `toml:"hash"`
+}
+
+// Manifest is the TOML sidecar written alongside a Backup archive. It
+// declares the schema version, the kind of lock.Manager the backup
+// came from, the object hash algorithm, and the signatures the backup
+// was taken at, so that Restore can verify compatibility and
+// dependency freshness before extracting anything.
+type Manifest struct {
+	SchemaVersion        int               `toml:"schema_version"`
+	Kind                 Kind              `toml:"kind"`
+	HashAlgorithm        string            `toml:"hash_algorithm"`
+	Signature            string            `toml:"signature"`
+	DependencySignatures map[string]string `toml:"dependency_signatures"`
+	Files                []FileEntry       `toml:"files"`
+}
+
+// Backup snapshots `files` (paths relative to `root`) into a
+// tar+gzip archive at `archivePath`, and writes a Manifest describing
+// them to `manifestPath`. `manager` supplies the Signature and
+// DependencySignatures recorded in the manifest; `kind` records which
+// flavor of lock.Manager produced them.
+//
+// This gives users a portable way to hand off pre-configured corpora,
+// samples, or configs between machines without re-running the
+// (potentially expensive) steps that produced them.
+func Backup(
+	manager Manager,
+	kind Kind,
+	root string,
+	files []string,
+	archivePath string,
+	manifestPath string,
+) error {
+	entries, archiveErr := writeArchive(archivePath, root, files)
+	if archiveErr != nil {
+		return archiveErr
+	}
+
+	manifest := Manifest{
+		SchemaVersion:        manifestSchemaVersion,
+		Kind:                 kind,
+		HashAlgorithm:        sha512Algorithm,
+		Signature:            manager.Signature(),
+		DependencySignatures: manager.DependencySignatures(),
+		Files:                entries,
+	}
+
+	return writeManifest(manifest, manifestPath)
+}
+
+// writeArchive snapshots `files` (paths relative to `root`) into a
+// tar+gzip archive at `archivePath`, returning the FileEntry recorded
+// for each. It writes to a temporary path alongside `archivePath` and
+// renames it into place only once the tar and gzip trailers have been
+// flushed successfully, so a failure partway through (e.g. disk full
+// on the final flush) never leaves a truncated archive on disk for a
+// manifest to vouch for.
+func writeArchive(archivePath string, root string, files []string) ([]FileEntry, error) {
+	tmpPath := archivePath + ".tmp"
+
+	archiveFile, createErr := os.Create(tmpPath)
+	if createErr != nil {
+		return nil, createErr
+	}
+
+	entries, writeErr := writeTarball(archiveFile, root, files)
+	closeErr := archiveFile.Close()
+
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return nil, writeErr
+	}
+	if closeErr != nil {
+		os.Remove(tmpPath)
+		return nil, closeErr
+	}
+
+	if renameErr := os.Rename(tmpPath, archivePath); renameErr != nil {
+		return nil, renameErr
+	}
+
+	return entries, nil
+}
+
+// writeTarball writes `files` (paths relative to `root`) into a
+// tar+gzip stream on `archiveFile`, closing the tar and gzip writers
+// (in that order) and propagating any error from doing so, since
+// that's where the final flush and trailer write happens.
+func writeTarball(archiveFile *os.File, root string, files []string) ([]FileEntry, error) {
+	gzipWriter := gzip.NewWriter(archiveFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	entries := make([]FileEntry, 0, len(files))
+	for _, relPath := range files {
+		entry, addErr := addFileToArchive(tarWriter, root, relPath)
+		if addErr != nil {
+			return nil, addErr
+		}
+		entries = append(entries, entry)
+	}
+
+	if closeErr := tarWriter.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+	if closeErr := gzipWriter.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+
+	return entries, nil
+}
+
+// addFileToArchive hashes and writes the file at `root`/`relPath`
+// into `tarWriter`, returning the FileEntry describing it.
+func addFileToArchive(tarWriter *tar.Writer, root string, relPath string) (FileEntry, error) {
+	absPath := filepath.Join(root, relPath)
+
+	file, openErr := os.Open(absPath)
+	if openErr != nil {
+		return FileEntry{}, openErr
+	}
+	defer file.Close()
+
+	info, statErr := file.Stat()
+	if statErr != nil {
+		return FileEntry{}, statErr
+	}
+
+	header, headerErr := tar.FileInfoHeader(info, "")
+	if headerErr != nil {
+		return FileEntry{}, headerErr
+	}
+	header.Name = filepath.ToSlash(relPath)
+
+	if writeHeaderErr := tarWriter.WriteHeader(header); writeHeaderErr != nil {
+		return FileEntry{}, writeHeaderErr
+	}
+
+	hasher := sha512.New()
+	if _, copyErr := io.Copy(io.MultiWriter(tarWriter, hasher), file); copyErr != nil {
+		return FileEntry{}, copyErr
+	}
+
+	return FileEntry{
+		Path: relPath,
+		Size: info.Size(),
+		Hash: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+func writeManifest(manifest Manifest, manifestPath string) error {
+	manifestFile, createErr := os.Create(manifestPath)
+	if createErr != nil {
+		return createErr
+	}
+	defer manifestFile.Close()
+
+	return toml.NewEncoder(manifestFile).Encode(manifest)
+}
+
+// ReadManifest deserializes the Manifest at `manifestPath`.
+func ReadManifest(manifestPath string) (Manifest, error) {
+	var manifest Manifest
+	_, decodeErr := toml.DecodeFile(manifestPath, &manifest)
+	return manifest, decodeErr
+}
+
+// Restore validates the Manifest at `manifestPath` against the
+// dependency managers currently present in `dependencyManagers`
+// (keyed the same way as Manifest.DependencySignatures), then
+// extracts `archivePath` into `root`. Restore refuses to touch a
+// `root` that already exists unless `force` is true, in which case
+// `root` is wiped before extracting, so the result always matches the
+// backed-up tree exactly rather than merging with whatever was there.
+func Restore(
+	manifestPath string,
+	archivePath string,
+	root string,
+	dependencyManagers map[string]Manager,
+	force bool,
+) error {
+	manifest, manifestErr := ReadManifest(manifestPath)
+	if manifestErr != nil {
+		return manifestErr
+	}
+
+	if manifest.SchemaVersion != manifestSchemaVersion {
+		return fmt.Errorf(
+			"manifest %s has schema version %d, but Restore only understands version %d",
+			manifestPath, manifest.SchemaVersion, manifestSchemaVersion)
+	}
+
+	for name, expectedSignature := range manifest.DependencySignatures {
+		dependency, ok := dependencyManagers[name]
+		if !ok {
+			return fmt.Errorf(
+				"manifest %s depends on %q, which has no corresponding LOCKFILE present",
+				manifestPath, name)
+		}
+
+		if dependency.Signature() != expectedSignature {
+			return fmt.Errorf(
+				"manifest %s was backed up against a different version of %q "+
+					"than is currently present; re-run the steps that produce "+
+					"it, or pass a manifest taken against the current version",
+				manifestPath, name)
+		}
+	}
+
+	if _, statErr := os.Stat(root); statErr == nil {
+		if !force {
+			return fmt.Errorf(
+				"%s already exists; pass force=true to overwrite it", root)
+		}
+
+		// Wipe root rather than merging, so files present in a
+		// previous restore but absent from this archive don't survive.
+		if removeErr := os.RemoveAll(root); removeErr != nil {
+			return removeErr
+		}
+	} else if !os.IsNotExist(statErr) {
+		return statErr
+	}
+
+	if mkdirErr := os.MkdirAll(root, 0755); mkdirErr != nil {
+		return mkdirErr
+	}
+
+	return extractArchive(archivePath, root, manifest)
+}
+
+// isWithinRoot reports whether destPath, once cleaned, is root itself
+// or a descendant of it. This guards against a "tar-slip" archive
+// entry (e.g. `../../.ssh/authorized_keys`) that would otherwise write
+// outside root.
+func isWithinRoot(root string, destPath string) bool {
+	cleanRoot := filepath.Clean(root)
+	cleanDest := filepath.Clean(destPath)
+
+	return cleanDest == cleanRoot ||
+		strings.HasPrefix(cleanDest, cleanRoot+string(filepath.Separator))
+}
+
+func extractArchive(archivePath string, root string, manifest Manifest) error {
+	archiveFile, openErr := os.Open(archivePath)
+	if openErr != nil {
+		return openErr
+	}
+	defer archiveFile.Close()
+
+	gzipReader, gzipErr := gzip.NewReader(archiveFile)
+	if gzipErr != nil {
+		return gzipErr
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	expectedHashes := make(map[string]string, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		expectedHashes[entry.Path] = entry.Hash
+	}
+
+	for {
+		header, nextErr := tarReader.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return fmt.Errorf(
+				"%s is not a regular file; archives produced by Backup never "+
+					"contain symlinks or other special files", header.Name)
+		}
+
+		destPath := filepath.Join(root, filepath.FromSlash(header.Name))
+		if !isWithinRoot(root, destPath) {
+			return fmt.Errorf(
+				"%s escapes the restore root %s", header.Name, root)
+		}
+
+		if mkdirErr := os.MkdirAll(filepath.Dir(destPath), 0755); mkdirErr != nil {
+			return mkdirErr
+		}
+
+		destFile, createErr := os.Create(destPath)
+		if createErr != nil {
+			return createErr
+		}
+
+		hasher := sha512.New()
+		_, copyErr := io.Copy(io.MultiWriter(destFile, hasher), tarReader)
+		destFile.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+
+		actualHash := hex.EncodeToString(hasher.Sum(nil))
+		if expectedHash, ok := expectedHashes[header.Name]; ok && actualHash != expectedHash {
+			return fmt.Errorf(
+				"%s was corrupted in transit: expected hash %s, got %s",
+				header.Name, expectedHash, actualHash)
+		}
+	}
+
+	return nil
+}